@@ -0,0 +1,124 @@
+//
+// Copyright (c) 2017 Jon Carlson.  All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// safeMode, when true, makes type-change statements pre-flight a validation
+// query that raises an exception if the conversion would lose data, instead
+// of letting postgres fail (or silently truncate) partway through the ALTER.
+var safeMode bool
+
+// castKey identifies a (fromType, toType) pair in the cast registry
+type castKey struct {
+	fromType string
+	toType   string
+}
+
+// CastRule describes how to safely convert a column from one data type to
+// another.  Using is a fmt template with a single %s placeholder for the
+// column name (e.g. "%s::integer"); ValidationQuery, if set, is a fmt
+// template with %s placeholders for (schema, table, column) that should
+// return zero rows when the conversion is safe.
+type CastRule struct {
+	FromType        string `json:"fromType"`
+	ToType          string `json:"toType"`
+	Using           string `json:"using"`
+	ValidationQuery string `json:"validationQuery"`
+}
+
+// castRegistry holds the built-in and user-registered cast rules, keyed by
+// (fromType, toType)
+var castRegistry = defaultCastRegistry()
+
+// defaultCastRegistry seeds the registry with the conversions that come up
+// often enough in real schemas to be worth a canned USING clause
+func defaultCastRegistry() map[castKey]CastRule {
+	const intRegexQuery = `SELECT 1 FROM %[1]s.%[2]s WHERE %[3]s IS NOT NULL AND %[3]s !~ '^\s*-?[0-9]+\s*$'`
+	const numericRegexQuery = `SELECT 1 FROM %[1]s.%[2]s WHERE %[3]s IS NOT NULL AND %[3]s !~ '^\s*-?[0-9]+(\.[0-9]+)?\s*$'`
+
+	rules := []CastRule{
+		{FromType: "text", ToType: "integer", Using: "%s::integer", ValidationQuery: intRegexQuery},
+		{FromType: "text", ToType: "bigint", Using: "%s::bigint", ValidationQuery: intRegexQuery},
+		{FromType: "text", ToType: "numeric", Using: "%s::numeric", ValidationQuery: numericRegexQuery},
+		{FromType: "text", ToType: "boolean", Using: "CASE %s WHEN 'true' THEN true WHEN 'false' THEN false ELSE NULL END", ValidationQuery: `SELECT 1 FROM %[1]s.%[2]s WHERE %[3]s IS NOT NULL AND lower(%[3]s) NOT IN ('true', 'false')`},
+		{FromType: "character varying", ToType: "integer", Using: "%s::integer", ValidationQuery: intRegexQuery},
+		{FromType: "timestamp without time zone", ToType: "timestamp with time zone", Using: "%s AT TIME ZONE 'UTC'"},
+		{FromType: "timestamp with time zone", ToType: "timestamp without time zone", Using: "%s AT TIME ZONE 'UTC'"},
+		{FromType: "bigint", ToType: "integer", Using: "%s::integer", ValidationQuery: `SELECT 1 FROM %[1]s.%[2]s WHERE %[3]s < -2147483648 OR %[3]s > 2147483647`},
+		{FromType: "numeric", ToType: "integer", Using: "%s::integer", ValidationQuery: `SELECT 1 FROM %[1]s.%[2]s WHERE %[3]s < -2147483648 OR %[3]s > 2147483647 OR %[3]s != trunc(%[3]s)`},
+	}
+
+	registry := make(map[castKey]CastRule, len(rules))
+	for _, rule := range rules {
+		registry[castKey{rule.FromType, rule.ToType}] = rule
+	}
+	return registry
+}
+
+// RegisterCastRule adds or overrides a cast rule in the registry so org-specific
+// conversions (e.g. text -> a custom domain) work without editing this file
+func RegisterCastRule(rule CastRule) {
+	castRegistry[castKey{rule.FromType, rule.ToType}] = rule
+}
+
+// LoadCastConfig reads a JSON file containing a list of CastRule objects and
+// registers each one, letting users extend the cast registry without a
+// rebuild. The file looks like:
+//
+//	[
+//	  {"fromType": "text", "toType": "uuid", "using": "%s::uuid"}
+//	]
+func LoadCastConfig(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening cast config %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var rules []CastRule
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return fmt.Errorf("parsing cast config %s: %w", path, err)
+	}
+
+	for _, rule := range rules {
+		RegisterCastRule(rule)
+	}
+	return nil
+}
+
+// lookupCast returns the registered cast rule for (fromType, toType), falling
+// back to a plain "column::toType" cast when nothing more specific is registered
+func lookupCast(fromType, toType, columnName string) CastRule {
+	if rule, ok := castRegistry[castKey{fromType, toType}]; ok {
+		return rule
+	}
+	return CastRule{FromType: fromType, ToType: toType, Using: "%s::" + toType}
+}
+
+// usingClause renders the rule's Using template with the given column name
+func (r CastRule) usingClause(columnName string) string {
+	return fmt.Sprintf(r.Using, columnName)
+}
+
+// preFlightCheck returns a DO block that raises an exception before the
+// ALTER runs if the rule's ValidationQuery would return any rows, or "" if
+// the rule has no ValidationQuery (conversions that can't lose data, like a
+// timezone-aware timestamp reinterpretation). The caller is responsible for
+// writing the result into the statement it's building -- printing it
+// directly here would bypass outputFormat "json" buffering.
+func (r CastRule) preFlightCheck(schema, table, columnName string) string {
+	if r.ValidationQuery == "" {
+		return ""
+	}
+	query := fmt.Sprintf(r.ValidationQuery, schema, table, columnName)
+	return fmt.Sprintf("DO $$\nBEGIN\n  IF EXISTS (%s) THEN\n    RAISE EXCEPTION 'unsafe type change for column %s.%s.%s: would lose data';\n  END IF;\nEND $$;\n", query, schema, table, columnName)
+}
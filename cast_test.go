@@ -0,0 +1,69 @@
+//
+// Copyright (c) 2017 Jon Carlson.  All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package main
+
+import "testing"
+
+func TestLookupCast(t *testing.T) {
+	cases := []struct {
+		name     string
+		fromType string
+		toType   string
+		want     string
+	}{
+		{name: "registered rule", fromType: "text", toType: "integer", want: "%s::integer"},
+		{name: "unregistered pair falls back", fromType: "integer", toType: "uuid", want: "%s::uuid"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rule := lookupCast(c.fromType, c.toType, "col")
+			if rule.Using != c.want {
+				t.Errorf("lookupCast(%q, %q).Using = %q, want %q", c.fromType, c.toType, rule.Using, c.want)
+			}
+		})
+	}
+}
+
+func TestUsingClause(t *testing.T) {
+	rule := lookupCast("text", "integer", "amount")
+	got := rule.usingClause("amount")
+	want := "amount::integer"
+	if got != want {
+		t.Errorf("usingClause() = %q, want %q", got, want)
+	}
+}
+
+func TestPreFlightCheck(t *testing.T) {
+	t.Run("no validation query", func(t *testing.T) {
+		rule := CastRule{FromType: "timestamp without time zone", ToType: "timestamp with time zone"}
+		if got := rule.preFlightCheck("public", "events", "seen_at"); got != "" {
+			t.Errorf("preFlightCheck() = %q, want empty string", got)
+		}
+	})
+
+	t.Run("validation query present", func(t *testing.T) {
+		rule := lookupCast("text", "integer", "amount")
+		got := rule.preFlightCheck("public", "orders", "amount")
+		if got == "" {
+			t.Fatal("preFlightCheck() returned empty string, want a DO block")
+		}
+		if want := "DO $$"; got[:len(want)] != want {
+			t.Errorf("preFlightCheck() = %q, want it to start with %q", got, want)
+		}
+	})
+}
+
+func TestRegisterCastRule(t *testing.T) {
+	defer delete(castRegistry, castKey{"text", "uuid"})
+
+	RegisterCastRule(CastRule{FromType: "text", ToType: "uuid", Using: "%s::uuid"})
+	rule := lookupCast("text", "uuid", "id")
+	if rule.Using != "%s::uuid" {
+		t.Errorf("lookupCast after RegisterCastRule: Using = %q, want %q", rule.Using, "%s::uuid")
+	}
+}
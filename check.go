@@ -0,0 +1,219 @@
+//
+// Copyright (c) 2017 Jon Carlson.  All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/joncrlsn/misc"
+	"github.com/joncrlsn/pgutil"
+)
+
+var (
+	checkSqlTemplate = initCheckSqlTemplate()
+)
+
+// Initializes the Sql template.  This joins pg_class to pg_constraint by oid
+// (cl.oid = con.conrelid), so two tables in the same schema sharing a
+// constraint name never get each other's check_clause attributed to them.
+func initCheckSqlTemplate() *template.Template {
+
+	sql := `
+SELECT n.nspname AS table_schema
+    ,  {{if eq $.DbSchema "*" }}n.nspname || '.' || {{end}}cl.relname AS table_key
+    , cl.relname AS table_name
+    , con.conname AS check_name
+    , pg_catalog.pg_get_constraintdef(con.oid, true) AS check_clause
+    , con.connoinherit AS check_no_inherit
+FROM pg_catalog.pg_constraint con
+INNER JOIN pg_catalog.pg_class cl ON cl.oid = con.conrelid
+INNER JOIN pg_catalog.pg_namespace n ON n.oid = cl.relnamespace
+WHERE con.contype = 'c'
+{{if eq $.DbSchema "*" }}
+AND n.nspname NOT LIKE 'pg_%'
+AND n.nspname <> 'information_schema'
+{{else}}
+AND n.nspname = '{{$.DbSchema}}'
+{{end}}
+;
+`
+	t := template.New("CheckSqlTmpl")
+	template.Must(t.Parse(sql))
+	return t
+}
+
+// ==================================
+// Check Rows definition
+// ==================================
+
+// CheckRows is a sortable slice of string maps
+type CheckRows []map[string]string
+
+func (slice CheckRows) Len() int {
+	return len(slice)
+}
+
+func (slice CheckRows) Less(i, j int) bool {
+	return slice[i]["compare_name"] < slice[j]["compare_name"]
+}
+
+func (slice CheckRows) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+// ==================================
+// CheckConstraintSchema definition
+// (implements Schema -- defined in pgdiff.go)
+// ==================================
+
+// CheckConstraintSchema holds a slice of rows from one of the databases as well as
+// a reference to the current row of data we're viewing.  It is the sole
+// source of CHECK constraint Add/Drop/Change SQL, covering both column-level
+// and table-level constraints; column.go does not emit them inline.
+type CheckConstraintSchema struct {
+	rows   CheckRows
+	rowNum int
+	done   bool
+}
+
+// get returns the value from the current row for the given key
+func (c *CheckConstraintSchema) get(key string) string {
+	if c.rowNum >= len(c.rows) {
+		return ""
+	}
+	return c.rows[c.rowNum][key]
+}
+
+// NextRow increments the rowNum and tells you whether or not there are more
+func (c *CheckConstraintSchema) NextRow() bool {
+	if c.rowNum >= len(c.rows)-1 {
+		c.done = true
+	}
+	c.rowNum = c.rowNum + 1
+	return !c.done
+}
+
+// setCompareName builds compare_name from the table key and the check
+// constraint's normalized expression rather than its name, so the same
+// constraint given a different auto-generated name on each side of the diff
+// (routine as tables are recreated/altered over time) still sorts to the
+// same position and is recognized as equivalent instead of producing a
+// spurious drop-and-add pair.
+func setCompareName(row map[string]string) {
+	row["compare_name"] = row["table_key"] + "." + normalizeCheckClause(row["check_clause"])
+}
+
+// Compare tells you, in one pass, whether or not the first row matches, is less than, or greater than the second row
+func (c *CheckConstraintSchema) Compare(obj interface{}) int {
+	c2, ok := obj.(*CheckConstraintSchema)
+	if !ok {
+		fmt.Println("Error!!!, Compare needs a CheckConstraintSchema instance", c2)
+	}
+
+	val := misc.CompareStrings(c.get("compare_name"), c2.get("compare_name"))
+	return val
+}
+
+// Add prints SQL to add the check constraint
+func (c *CheckConstraintSchema) Add() {
+	schema := dbInfo2.DbSchema
+	if schema == "*" {
+		schema = c.get("table_schema")
+	}
+
+	noInherit := ""
+	if c.get("check_no_inherit") == "true" {
+		noInherit = " NO INHERIT"
+	}
+
+	ref := ObjectRef{Schema: schema, Table: c.get("table_name"), Name: c.get("check_name")}
+	sqlText := fmt.Sprintf("ALTER TABLE %s.%s ADD CONSTRAINT %s %s%s;\n", schema, c.get("table_name"), c.get("check_name"), c.get("check_clause"), noInherit)
+	emitSQL("add", ref, nil, toAnyMap(c.rows[c.rowNum]), sqlText)
+}
+
+// Drop prints SQL to drop the check constraint
+func (c *CheckConstraintSchema) Drop() {
+	ref := ObjectRef{Schema: c.get("table_schema"), Table: c.get("table_name"), Name: c.get("check_name")}
+	sqlText := fmt.Sprintf("ALTER TABLE %s.%s DROP CONSTRAINT IF EXISTS %s;\n", c.get("table_schema"), c.get("table_name"), c.get("check_name"))
+	emitSQL("drop", ref, toAnyMap(c.rows[c.rowNum]), nil, sqlText)
+}
+
+// Change handles the case where the table and normalized expression match
+// (that's what compare_name is keyed on) but something else about the
+// constraint differs -- in practice, only check_no_inherit can differ here,
+// since the name itself isn't part of the generated SQL's identity check.
+func (c *CheckConstraintSchema) Change(obj interface{}) {
+	c2, ok := obj.(*CheckConstraintSchema)
+	if !ok {
+		fmt.Println("Error!!!, CheckConstraintSchema.Change(obj) needs a CheckConstraintSchema instance", c2)
+	}
+
+	if c.get("check_no_inherit") == c2.get("check_no_inherit") {
+		// Only the name differed; nothing to do.
+		return
+	}
+
+	noInherit := ""
+	if c.get("check_no_inherit") == "true" {
+		noInherit = " NO INHERIT"
+	}
+
+	sqlText := fmt.Sprintf("ALTER TABLE %s.%s DROP CONSTRAINT %s;\n", c2.get("table_schema"), c.get("table_name"), c2.get("check_name"))
+	sqlText += fmt.Sprintf("ALTER TABLE %s.%s ADD CONSTRAINT %s %s%s NOT VALID;\n", c2.get("table_schema"), c.get("table_name"), c.get("check_name"), c.get("check_clause"), noInherit)
+
+	ref := ObjectRef{Schema: c2.get("table_schema"), Table: c.get("table_name"), Name: c.get("check_name")}
+	emitSQL("change", ref, toAnyMap(c2.rows[c2.rowNum]), toAnyMap(c.rows[c.rowNum]), sqlText)
+}
+
+// normalizeCheckClause strips incidental whitespace differences so unnamed
+// constraints compare by their effective expression rather than formatting
+func normalizeCheckClause(clause string) string {
+	fields := strings.Fields(clause)
+	return strings.Join(fields, " ")
+}
+
+// ==================================
+// Standalone Functions
+// ==================================
+
+// compareChecks outputs SQL to make the check constraints match between two databases or schemas
+func compareChecks(conn1 *sql.DB, conn2 *sql.DB) {
+	buf1 := new(bytes.Buffer)
+	checkSqlTemplate.Execute(buf1, dbInfo1)
+
+	buf2 := new(bytes.Buffer)
+	checkSqlTemplate.Execute(buf2, dbInfo2)
+
+	rowChan1, _ := pgutil.QueryStrings(conn1, buf1.String())
+	rowChan2, _ := pgutil.QueryStrings(conn2, buf2.String())
+
+	rows1 := make(CheckRows, 0)
+	for row := range rowChan1 {
+		setCompareName(row)
+		rows1 = append(rows1, row)
+	}
+	sort.Sort(rows1)
+
+	rows2 := make(CheckRows, 0)
+	for row := range rowChan2 {
+		setCompareName(row)
+		rows2 = append(rows2, row)
+	}
+	sort.Sort(rows2)
+
+	// We have to explicitly type this as Schema here for some unknown reason
+	var schema1 Schema = &CheckConstraintSchema{rows: rows1, rowNum: -1}
+	var schema2 Schema = &CheckConstraintSchema{rows: rows2, rowNum: -1}
+
+	// Compare the check constraints
+	doDiff(schema1, schema2)
+}
@@ -0,0 +1,68 @@
+//
+// Copyright (c) 2017 Jon Carlson.  All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package main
+
+import "testing"
+
+// TestSetCompareNameIgnoresConstraintName guards against regressing to a
+// name-based compare_name -- two rows for the same table and expression but
+// different (e.g. auto-generated) constraint names must produce the same
+// compare_name so they match up instead of diffing as a spurious drop+add.
+func TestSetCompareNameIgnoresConstraintName(t *testing.T) {
+	row1 := map[string]string{"table_key": "events", "check_name": "events_check", "check_clause": "CHECK (age > 0)"}
+	row2 := map[string]string{"table_key": "events", "check_name": "events_age_check1", "check_clause": "CHECK  (age  >  0)"}
+
+	setCompareName(row1)
+	setCompareName(row2)
+
+	if row1["compare_name"] != row2["compare_name"] {
+		t.Errorf("compare_name differed for the same table/expression with different names: %q vs %q", row1["compare_name"], row2["compare_name"])
+	}
+}
+
+func TestNormalizeCheckClause(t *testing.T) {
+	cases := []struct {
+		name string
+		a    string
+		b    string
+		want bool
+	}{
+		{
+			name: "identical",
+			a:    "CHECK (age > 0)",
+			b:    "CHECK (age > 0)",
+			want: true,
+		},
+		{
+			name: "whitespace only differs",
+			a:    "CHECK  (age  >  0)",
+			b:    "CHECK (age > 0)",
+			want: true,
+		},
+		{
+			name: "newlines and tabs collapse",
+			a:    "CHECK (age > 0\n\tAND age < 150)",
+			b:    "CHECK (age > 0 AND age < 150)",
+			want: true,
+		},
+		{
+			name: "different expression",
+			a:    "CHECK (age > 0)",
+			b:    "CHECK (age >= 0)",
+			want: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := normalizeCheckClause(c.a) == normalizeCheckClause(c.b)
+			if got != c.want {
+				t.Errorf("normalizeCheckClause(%q) == normalizeCheckClause(%q) = %v, want %v", c.a, c.b, got, c.want)
+			}
+		})
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"database/sql"
 	"fmt"
 	"sort"
-	"strconv"
 	"strings"
 	"text/template"
 
@@ -43,12 +42,38 @@ SELECT table_schema
     , character_maximum_length
     , is_identity
     , identity_generation
+    , identity_start
+    , identity_increment
+    , identity_minimum
+    , identity_maximum
+    , identity_cycle
+    , generation_expression
+    , seq.identity_cache
     , substring(udt_name from 2) AS array_type
+    , collation_name
+    , attr.attstorage AS storage
+    , attr.attcompression AS compression
+    , pg_catalog.col_description(pgc.oid, columns.ordinal_position) AS column_comment
 FROM information_schema.columns
+LEFT JOIN pg_catalog.pg_class pgc
+    ON pgc.relname = columns.table_name
+   AND pgc.relnamespace = (SELECT oid FROM pg_catalog.pg_namespace WHERE nspname = columns.table_schema)
+LEFT JOIN pg_catalog.pg_attribute attr
+    ON attr.attrelid = pgc.oid
+   AND attr.attname = columns.column_name
+   AND attr.attnum > 0
+   AND NOT attr.attisdropped
+LEFT JOIN LATERAL (
+    SELECT s.seqcache AS identity_cache
+    FROM pg_catalog.pg_sequence s
+    WHERE columns.is_identity = 'YES'
+      AND s.seqrelid = pg_catalog.pg_get_serial_sequence(columns.table_schema || '.' || columns.table_name, columns.column_name)::regclass::oid
+    LIMIT 1
+) seq ON true
 WHERE is_updatable = 'YES'
 {{if eq $.DbSchema "*" }}
-AND table_schema NOT LIKE 'pg_%' 
-AND table_schema <> 'information_schema' 
+AND table_schema NOT LIKE 'pg_%'
+AND table_schema <> 'information_schema'
 {{else}}
 AND table_schema = '{{$.DbSchema}}'
 {{end}}
@@ -74,11 +99,39 @@ SELECT a.table_schema
     , is_nullable
     , column_default
     , character_maximum_length
+    , is_identity
+    , identity_generation
+    , identity_start
+    , identity_increment
+    , identity_minimum
+    , identity_maximum
+    , identity_cycle
+    , generation_expression
+    , seq.identity_cache
+    , collation_name
+    , attr.attstorage AS storage
+    , attr.attcompression AS compression
+    , pg_catalog.col_description(pgc.oid, a.ordinal_position) AS column_comment
 FROM information_schema.columns a
 INNER JOIN information_schema.tables b
     ON a.table_schema = b.table_schema AND
        a.table_name = b.table_name AND
        b.table_type = 'BASE TABLE'
+LEFT JOIN pg_catalog.pg_class pgc
+    ON pgc.relname = a.table_name
+   AND pgc.relnamespace = (SELECT oid FROM pg_catalog.pg_namespace WHERE nspname = a.table_schema)
+LEFT JOIN pg_catalog.pg_attribute attr
+    ON attr.attrelid = pgc.oid
+   AND attr.attname = a.column_name
+   AND attr.attnum > 0
+   AND NOT attr.attisdropped
+LEFT JOIN LATERAL (
+    SELECT s.seqcache AS identity_cache
+    FROM pg_catalog.pg_sequence s
+    WHERE a.is_identity = 'YES'
+      AND s.seqrelid = pg_catalog.pg_get_serial_sequence(a.table_schema || '.' || a.table_name, a.column_name)::regclass::oid
+    LIMIT 1
+) seq ON true
 WHERE is_updatable = 'YES'
 {{if eq $.DbSchema "*" }}
 AND a.table_schema NOT LIKE 'pg_%' 
@@ -165,48 +218,71 @@ func (c *ColumnSchema) Add() {
 	}
 
 	// Knowing the version of db2 would eliminate the need for this warning
-	if c.get("is_identity") == "YES" {
+	if c.get("is_identity") == "YES" && outputFormat != "json" {
 		fmt.Println("-- WARNING: identity columns are not supported in PostgreSQL versions < 10.")
 		fmt.Println("-- Attempting to create identity columns in earlier versions will probably result in errors.")
 	}
 
+	var stmt strings.Builder
+
 	if c.get("data_type") == "character varying" {
 		maxLength, valid := getMaxLength(c.get("character_maximum_length"))
 		if !valid {
-			fmt.Printf("ALTER TABLE %s.%s ADD COLUMN %s character varying", schema, c.get("table_name"), c.get("column_name"))
+			fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ADD COLUMN %s character varying", schema, c.get("table_name"), c.get("column_name"))
 		} else {
-			fmt.Printf("ALTER TABLE %s.%s ADD COLUMN %s character varying(%s)", schema, c.get("table_name"), c.get("column_name"), maxLength)
+			fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ADD COLUMN %s character varying(%s)", schema, c.get("table_name"), c.get("column_name"), maxLength)
 		}
 	} else {
 		dataType := c.get("data_type")
-		//if c.get("data_type") == "ARRAY" {
-		//fmt.Println("-- Note that adding of array data types are not yet generated properly.")
-		//}
 		if dataType == "ARRAY" {
 			dataType = c.get("array_type") + "[]"
 		}
-		//fmt.Printf("ALTER TABLE %s.%s ADD COLUMN %s %s", schema, c.get("table_name"), c.get("column_name"), c.get("data_type"))
-		fmt.Printf("ALTER TABLE %s.%s ADD COLUMN %s %s", schema, c.get("table_name"), c.get("column_name"), dataType)
+		fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ADD COLUMN %s %s", schema, c.get("table_name"), c.get("column_name"), dataType)
 	}
 
+	if c.get("collation_name") != "null" && c.get("collation_name") != "" {
+		fmt.Fprintf(&stmt, " COLLATE \"%s\"", c.get("collation_name"))
+	}
 	if c.get("is_nullable") == "NO" {
-		fmt.Printf(" NOT NULL")
+		fmt.Fprintf(&stmt, " NOT NULL")
 	}
-	if c.get("column_default") != "null" {
-		fmt.Printf(" DEFAULT %s", c.get("column_default"))
+	if c.get("generation_expression") != "null" && c.get("generation_expression") != "" {
+		fmt.Fprintf(&stmt, " GENERATED ALWAYS AS (%s) STORED", c.get("generation_expression"))
+	} else if c.get("column_default") != "null" {
+		fmt.Fprintf(&stmt, " DEFAULT %s", c.get("column_default"))
 	}
-	// NOTE: there are more identity column sequence options according to the PostgreSQL
-	// CREATE TABLE docs, but these do not appear to be available as of version 10.1
 	if c.get("is_identity") == "YES" {
-		fmt.Printf(" GENERATED %s AS IDENTITY", c.get("identity_generation"))
+		fmt.Fprintf(&stmt, " GENERATED %s AS IDENTITY (%s)", c.get("identity_generation"), c.identitySequenceOptions())
+	}
+	// Any CHECK constraint on this column is added separately by
+	// CheckConstraintSchema (check.go) so it isn't emitted twice.
+	fmt.Fprintf(&stmt, ";\n")
+
+	ref := ObjectRef{Schema: schema, Table: c.get("table_name"), Column: c.get("column_name")}
+	emitSQL("add", ref, nil, toAnyMap(c.rows[c.rowNum]), stmt.String())
+}
+
+// identitySequenceOptions builds the "START WITH ... INCREMENT BY ... MINVALUE ...
+// MAXVALUE ... CACHE ... [CYCLE]" clause that follows "GENERATED ... AS IDENTITY"
+func (c *ColumnSchema) identitySequenceOptions() string {
+	parts := []string{
+		fmt.Sprintf("START WITH %s", c.get("identity_start")),
+		fmt.Sprintf("INCREMENT BY %s", c.get("identity_increment")),
+		fmt.Sprintf("MINVALUE %s", c.get("identity_minimum")),
+		fmt.Sprintf("MAXVALUE %s", c.get("identity_maximum")),
+		fmt.Sprintf("CACHE %s", c.get("identity_cache")),
 	}
-	fmt.Printf(";\n")
+	if c.get("identity_cycle") == "YES" {
+		parts = append(parts, "CYCLE")
+	}
+	return strings.Join(parts, " ")
 }
 
 // Drop prints SQL to drop the column
 func (c *ColumnSchema) Drop() {
-	// if dropping column
-	fmt.Printf("ALTER TABLE %s.%s DROP COLUMN IF EXISTS %s;\n", c.get("table_schema"), c.get("table_name"), c.get("column_name"))
+	ref := ObjectRef{Schema: c.get("table_schema"), Table: c.get("table_name"), Column: c.get("column_name")}
+	sqlText := fmt.Sprintf("ALTER TABLE %s.%s DROP COLUMN IF EXISTS %s;\n", c.get("table_schema"), c.get("table_name"), c.get("column_name"))
+	emitSQL("drop", ref, toAnyMap(c.rows[c.rowNum]), nil, sqlText)
 }
 
 // Change handles the case where the table and column match, but the details do not
@@ -216,6 +292,8 @@ func (c *ColumnSchema) Change(obj interface{}) {
 		fmt.Println("Error!!!, ColumnSchema.Change(obj) needs a ColumnSchema instance", c2)
 	}
 
+	var stmt strings.Builder
+
 	// Adjust data type for array columns
 	dataType1 := c.get("data_type")
 	if dataType1 == "ARRAY" {
@@ -231,47 +309,50 @@ func (c *ColumnSchema) Change(obj interface{}) {
 	if dataType1 == dataType2 {
 		if dataType1 == "character varying" {
 			max1, max1Valid := getMaxLength(c.get("character_maximum_length"))
-			max2, max2Valid := getMaxLength(c2.get("character_maximum_length"))
+			_, max2Valid := getMaxLength(c2.get("character_maximum_length"))
 			if !max1Valid && !max2Valid {
 				// Leave them alone, they both have undefined max lengths
 			} else if (max1Valid || !max2Valid) && (max1 != c2.get("character_maximum_length")) {
-				//if !max1Valid {
-				//    fmt.Println("-- WARNING: varchar column has no maximum length.  Setting to 1024, which may result in data loss.")
-				//}
-				max1Int, err1 := strconv.Atoi(max1)
-				check("converting string to int", err1)
-				max2Int, err2 := strconv.Atoi(max2)
-				check("converting string to int", err2)
-				if max1Int < max2Int {
-					fmt.Println("-- WARNING: The next statement will shorten a character varying column, which may result in data loss.")
+				meta1 := parseColumnMeta(c.rows[c.rowNum])
+				meta2 := parseColumnMeta(c2.rows[c2.rowNum])
+				if meta1.CharacterMaximumLength < meta2.CharacterMaximumLength {
+					if outputFormat != "json" {
+						fmt.Println("-- WARNING: The next statement will shorten a character varying column, which may result in data loss.")
+					}
+					if safeMode {
+						fmt.Fprintf(&stmt, "DO $$\nBEGIN\n  IF EXISTS (SELECT 1 FROM %s.%s WHERE length(%s) > %s) THEN\n    RAISE EXCEPTION 'unsafe type change for column %s.%s.%s: existing values are longer than %s';\n  END IF;\nEND $$;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), max1, c2.get("table_schema"), c.get("table_name"), c.get("column_name"), max1)
+					}
 				}
-				fmt.Printf("-- max1Valid: %v  max2Valid: %v \n", max1Valid, max2Valid)
-				fmt.Printf("ALTER TABLE %s.%s ALTER COLUMN %s TYPE character varying(%s);\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), max1)
+				fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ALTER COLUMN %s TYPE character varying(%s);\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), max1)
 			}
 		}
 	}
 
-	// Code and test a column change from integer to bigint
+	// Detect a column type change and convert it using the registered cast
+	// rule for (dataType2, dataType1), falling back to a plain "::newtype" cast
 	if dataType1 != dataType2 {
-		fmt.Printf("-- WARNING: This type change may not work well: (%s to %s).\n", dataType2, dataType1)
+		cast := lookupCast(dataType2, dataType1, c.get("column_name"))
+		if safeMode {
+			fmt.Fprint(&stmt, cast.preFlightCheck(c2.get("table_schema"), c.get("table_name"), c.get("column_name")))
+		}
+		typeClause := dataType1
 		if strings.HasPrefix(dataType1, "character") {
 			max1, max1Valid := getMaxLength(c.get("character_maximum_length"))
-			if !max1Valid {
+			if !max1Valid && outputFormat != "json" {
 				fmt.Println("-- WARNING: varchar column has no maximum length.  Setting to 1024")
 			}
-			fmt.Printf("ALTER TABLE %s.%s ALTER COLUMN %s TYPE %s(%s);\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), dataType1, max1)
-		} else {
-			fmt.Printf("ALTER TABLE %s.%s ALTER COLUMN %s TYPE %s;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), dataType1)
+			typeClause = fmt.Sprintf("%s(%s)", dataType1, max1)
 		}
+		fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ALTER COLUMN %s TYPE %s USING %s;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), typeClause, cast.usingClause(c.get("column_name")))
 	}
 
 	// Detect column default change (or added, dropped)
 	if c.get("column_default") == "null" {
 		if c2.get("column_default") != "null" {
-			fmt.Printf("ALTER TABLE %s.%s ALTER COLUMN %s DROP DEFAULT;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"))
+			fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ALTER COLUMN %s DROP DEFAULT;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"))
 		}
 	} else if c.get("column_default") != c2.get("column_default") {
-		fmt.Printf("ALTER TABLE %s.%s ALTER COLUMN %s SET DEFAULT %s;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), c.get("column_default"))
+		fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ALTER COLUMN %s SET DEFAULT %s;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), c.get("column_default"))
 	}
 
 	// Detect identity column change
@@ -280,33 +361,118 @@ func (c *ColumnSchema) Change(obj interface{}) {
 	var identitySql string
 	if c.get("is_identity") != c2.get("is_identity") {
 		// Knowing the version of db2 would eliminate the need for this warning
-		fmt.Println("-- WARNING: identity columns are not supported in PostgreSQL versions < 10.")
-		fmt.Println("-- Attempting to create identity columns in earlier versions will probably result in errors.")
+		if outputFormat != "json" {
+			fmt.Println("-- WARNING: identity columns are not supported in PostgreSQL versions < 10.")
+			fmt.Println("-- Attempting to create identity columns in earlier versions will probably result in errors.")
+		}
 		if c.get("is_identity") == "YES" {
-			identitySql = fmt.Sprintf("ALTER TABLE \"%s\".\"%s\" ALTER COLUMN \"%s\" ADD GENERATED %s AS IDENTITY;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), c.get("identity_generation"))
+			identitySql = fmt.Sprintf("ALTER TABLE \"%s\".\"%s\" ALTER COLUMN \"%s\" ADD GENERATED %s AS IDENTITY (%s);\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), c.get("identity_generation"), c.identitySequenceOptions())
 		} else {
 			identitySql = fmt.Sprintf("ALTER TABLE \"%s\".\"%s\" ALTER COLUMN \"%s\" DROP IDENTITY;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"))
 		}
+	} else if c.get("is_identity") == "YES" {
+		// Both sides are identity columns; check whether the generation mode
+		// or any of the sequence options drifted
+		if c.get("identity_generation") != c2.get("identity_generation") {
+			fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ALTER COLUMN %s SET GENERATED %s;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), c.get("identity_generation"))
+		}
+		if c.get("identity_start") != c2.get("identity_start") ||
+			c.get("identity_increment") != c2.get("identity_increment") ||
+			c.get("identity_minimum") != c2.get("identity_minimum") ||
+			c.get("identity_maximum") != c2.get("identity_maximum") ||
+			c.get("identity_cycle") != c2.get("identity_cycle") ||
+			c.get("identity_cache") != c2.get("identity_cache") {
+			cycle := "SET NO CYCLE"
+			if c.get("identity_cycle") == "YES" {
+				cycle = "SET CYCLE"
+			}
+			// Each sequence_option needs its own SET (or RESTART) keyword;
+			// they can all be chained onto one ALTER COLUMN clause, but
+			// "SET INCREMENT BY n MINVALUE n ..." without repeating SET is
+			// a syntax error.
+			fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ALTER COLUMN %s SET INCREMENT BY %s SET MINVALUE %s SET MAXVALUE %s SET CACHE %s %s RESTART WITH %s;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), c.get("identity_increment"), c.get("identity_minimum"), c.get("identity_maximum"), c.get("identity_cache"), cycle, c.get("identity_start"))
+		}
+	}
+
+	// Detect generated (computed) column expression change
+	if c.get("generation_expression") != c2.get("generation_expression") {
+		if outputFormat != "json" {
+			fmt.Println("-- WARNING: changing a generated column's expression requires dropping and re-adding the column; doing so here would lose data.")
+		}
+		fmt.Fprintf(&stmt, "-- ALTER TABLE %s.%s DROP COLUMN %s, ADD COLUMN %s %s GENERATED ALWAYS AS (%s) STORED;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), c.get("column_name"), dataType1, c.get("generation_expression"))
 	}
 
 	// Detect not-null and nullable change
 	if c.get("is_nullable") != c2.get("is_nullable") {
 		if c.get("is_nullable") == "YES" {
 			if identitySql != "" {
-				fmt.Printf(identitySql)
+				fmt.Fprint(&stmt, identitySql)
 			}
-			fmt.Printf("ALTER TABLE %s.%s ALTER COLUMN %s DROP NOT NULL;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"))
+			fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ALTER COLUMN %s DROP NOT NULL;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"))
 		} else {
-			fmt.Printf("ALTER TABLE %s.%s ALTER COLUMN %s SET NOT NULL;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"))
+			fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ALTER COLUMN %s SET NOT NULL;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"))
 			if identitySql != "" {
-				fmt.Printf(identitySql)
+				fmt.Fprint(&stmt, identitySql)
 			}
 		}
 	} else {
 		if identitySql != "" {
-			fmt.Printf(identitySql)
+			fmt.Fprint(&stmt, identitySql)
 		}
 	}
+
+	// Detect collation change. An empty/null collation_name on c means "database
+	// default collation" rather than "no collation applies" (plain numeric types
+	// never have one either; when the data type changed, the TYPE statement above
+	// already left the column on its new type's default collation, so only a
+	// named collation is restated then) -- so a drift to or from the default
+	// needs to emit COLLATE "default" just as much as a drift between two named
+	// collations.
+	collation := c.get("collation_name")
+	if collation == "null" || collation == "" {
+		collation = "default"
+	}
+	if c.get("collation_name") != c2.get("collation_name") && (dataType1 == dataType2 || collation != "default") {
+		typeClause := dataType1
+		if strings.HasPrefix(dataType1, "character") {
+			max1, _ := getMaxLength(c.get("character_maximum_length"))
+			typeClause = fmt.Sprintf("%s(%s)", dataType1, max1)
+		}
+		fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ALTER COLUMN %s TYPE %s COLLATE \"%s\";\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), typeClause, collation)
+	}
+
+	// Detect storage change
+	if c.get("storage") != c2.get("storage") && c.get("storage") != "" {
+		if storage, ok := storageModeNames[c.get("storage")]; ok {
+			fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ALTER COLUMN %s SET STORAGE %s;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), storage)
+		}
+	}
+
+	// Detect TOAST compression change (PG14+)
+	if c.get("compression") != c2.get("compression") && c.get("compression") != "" {
+		if compression, ok := compressionMethodNames[c.get("compression")]; ok {
+			fmt.Fprintf(&stmt, "ALTER TABLE %s.%s ALTER COLUMN %s SET COMPRESSION %s;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), compression)
+		}
+	}
+
+	// CHECK constraint drift is handled entirely by CheckConstraintSchema
+	// (check.go), which diffs pg_constraint directly; it isn't repeated here.
+
+	// Detect column comment change
+	if c.get("column_comment") != c2.get("column_comment") {
+		if c.get("column_comment") == "null" {
+			fmt.Fprintf(&stmt, "COMMENT ON COLUMN %s.%s.%s IS NULL;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"))
+		} else {
+			fmt.Fprintf(&stmt, "COMMENT ON COLUMN %s.%s.%s IS %s;\n", c2.get("table_schema"), c.get("table_name"), c.get("column_name"), quoteCommentLiteral(c.get("column_comment")))
+		}
+	}
+
+	if stmt.Len() == 0 {
+		return
+	}
+
+	ref := ObjectRef{Schema: c2.get("table_schema"), Table: c.get("table_name"), Column: c.get("column_name")}
+	emitSQL("change", ref, toAnyMap(c2.rows[c2.rowNum]), toAnyMap(c.rows[c.rowNum]), stmt.String())
 }
 
 // ==================================
@@ -324,6 +490,11 @@ func compare(conn1 *sql.DB, conn2 *sql.DB, tpl *template.Template) {
 	rowChan1, _ := pgutil.QueryStrings(conn1, buf1.String())
 	rowChan2, _ := pgutil.QueryStrings(conn2, buf2.String())
 
+	if streamDiff {
+		mergeDiffColumns(rowChan1, rowChan2)
+		return
+	}
+
 	//rows1 := make([]map[string]string, 500)
 	rows1 := make(ColumnRows, 0)
 	for row := range rowChan1 {
@@ -370,3 +541,19 @@ func getMaxLength(maxLength string) (string, bool) {
 	}
 	return maxLength, true
 }
+
+// storageModeNames maps pg_attribute.attstorage's single-character code to the
+// keyword SET STORAGE expects
+var storageModeNames = map[string]string{
+	"p": "PLAIN",
+	"e": "EXTERNAL",
+	"m": "MAIN",
+	"x": "EXTENDED",
+}
+
+// compressionMethodNames maps pg_attribute.attcompression's single-character
+// code to the keyword SET COMPRESSION expects (PG14+; empty means "default")
+var compressionMethodNames = map[string]string{
+	"p": "pglz",
+	"l": "lz4",
+}
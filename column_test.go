@@ -0,0 +1,79 @@
+//
+// Copyright (c) 2017 Jon Carlson.  All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joncrlsn/pgutil"
+)
+
+func identityColumn(start, increment, minimum, maximum, cycle, cache string) map[string]string {
+	row := column("public", "events", "id")
+	row["is_identity"] = "YES"
+	row["identity_generation"] = "ALWAYS"
+	row["identity_start"] = start
+	row["identity_increment"] = increment
+	row["identity_minimum"] = minimum
+	row["identity_maximum"] = maximum
+	row["identity_cycle"] = cycle
+	row["identity_cache"] = cache
+	return row
+}
+
+// lastChangeSQL runs schema1.Change(schema2) and returns the SQL text of the
+// single Change record it's expected to emit.
+func lastChangeSQL(t *testing.T, row1, row2 map[string]string) string {
+	t.Helper()
+
+	outputFormat = "json"
+	changes = nil
+	dbInfo2 = &pgutil.DbInfo{DbSchema: "public"}
+	defer func() {
+		outputFormat = "sql"
+		changes = nil
+		dbInfo2 = nil
+	}()
+
+	schema1 := &ColumnSchema{rows: ColumnRows{row1}, rowNum: 0}
+	schema2 := &ColumnSchema{rows: ColumnRows{row2}, rowNum: 0}
+	schema1.Change(schema2)
+
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, want 1", len(changes))
+	}
+	return changes[0].SQL
+}
+
+// TestIdentitySequenceDriftIsOneValidStatement guards against regressing to
+// "SET INCREMENT BY n MINVALUE n ..." -- each sequence_option after the
+// first needs its own SET (or RESTART) keyword, or Postgres rejects the
+// statement outright.
+func TestIdentitySequenceDriftIsOneValidStatement(t *testing.T) {
+	row1 := identityColumn("100", "5", "1", "1000000", "YES", "10")
+	row2 := identityColumn("1", "1", "1", "2147483647", "NO", "1")
+
+	sql := lastChangeSQL(t, row1, row2)
+
+	for _, want := range []string{
+		"SET INCREMENT BY 5",
+		"SET MINVALUE 1",
+		"SET MAXVALUE 1000000",
+		"SET CACHE 10",
+		"SET CYCLE",
+		"RESTART WITH 100",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("generated SQL %q missing %q", sql, want)
+		}
+	}
+
+	if strings.Count(sql, "ALTER TABLE") != 1 {
+		t.Errorf("expected the sequence option drift to be a single ALTER TABLE statement, got: %q", sql)
+	}
+}
@@ -0,0 +1,215 @@
+//
+// Copyright (c) 2017 Jon Carlson.  All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/joncrlsn/misc"
+	"github.com/joncrlsn/pgutil"
+)
+
+var (
+	commentSqlTemplate = initCommentSqlTemplate()
+)
+
+// Initializes the Sql template.  Column comments are handled inline by
+// ColumnSchema.Change (column.go); this template only covers the schema
+// and table objects themselves.
+func initCommentSqlTemplate() *template.Template {
+
+	sql := `
+SELECT 'schema' AS object_type
+     , n.nspname AS table_schema
+     , '' AS table_name
+     , 'schema:' || n.nspname AS compare_name
+     , pg_catalog.obj_description(n.oid, 'pg_namespace') AS object_comment
+FROM pg_catalog.pg_namespace n
+WHERE 1=1
+{{if eq $.DbSchema "*" }}
+AND n.nspname NOT LIKE 'pg_%'
+AND n.nspname <> 'information_schema'
+{{else}}
+AND n.nspname = '{{$.DbSchema}}'
+{{end}}
+UNION ALL
+SELECT 'table' AS object_type
+     , n.nspname AS table_schema
+     , c.relname AS table_name
+     , {{if eq $.DbSchema "*" }}'table:' || n.nspname || '.' || c.relname{{else}}'table:' || c.relname{{end}} AS compare_name
+     , pg_catalog.obj_description(c.oid, 'pg_class') AS object_comment
+FROM pg_catalog.pg_class c
+INNER JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p')
+{{if eq $.DbSchema "*" }}
+AND n.nspname NOT LIKE 'pg_%'
+AND n.nspname <> 'information_schema'
+{{else}}
+AND n.nspname = '{{$.DbSchema}}'
+{{end}}
+ORDER BY compare_name ASC;
+`
+	t := template.New("CommentSqlTmpl")
+	template.Must(t.Parse(sql))
+	return t
+}
+
+// ==================================
+// Comment Rows definition
+// ==================================
+
+// CommentRows is a sortable slice of string maps
+type CommentRows []map[string]string
+
+func (slice CommentRows) Len() int {
+	return len(slice)
+}
+
+func (slice CommentRows) Less(i, j int) bool {
+	return slice[i]["compare_name"] < slice[j]["compare_name"]
+}
+
+func (slice CommentRows) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+// ==================================
+// CommentSchema definition
+// (implements Schema -- defined in pgdiff.go)
+// ==================================
+
+// CommentSchema holds a slice of rows from one of the databases as well as
+// a reference to the current row of data we're viewing.  It covers schema
+// and table comments; column comments are diffed by ColumnSchema.
+type CommentSchema struct {
+	rows   CommentRows
+	rowNum int
+	done   bool
+}
+
+// get returns the value from the current row for the given key
+func (c *CommentSchema) get(key string) string {
+	if c.rowNum >= len(c.rows) {
+		return ""
+	}
+	return c.rows[c.rowNum][key]
+}
+
+// NextRow increments the rowNum and tells you whether or not there are more
+func (c *CommentSchema) NextRow() bool {
+	if c.rowNum >= len(c.rows)-1 {
+		c.done = true
+	}
+	c.rowNum = c.rowNum + 1
+	return !c.done
+}
+
+// Compare tells you, in one pass, whether or not the first row matches, is less than, or greater than the second row
+func (c *CommentSchema) Compare(obj interface{}) int {
+	c2, ok := obj.(*CommentSchema)
+	if !ok {
+		fmt.Println("Error!!!, Compare needs a CommentSchema instance", c2)
+	}
+
+	val := misc.CompareStrings(c.get("compare_name"), c2.get("compare_name"))
+	return val
+}
+
+// commentTarget returns the "ON ..." clause identifying the schema or table this row describes
+func (c *CommentSchema) commentTarget(schema string) string {
+	if c.get("object_type") == "schema" {
+		return fmt.Sprintf("SCHEMA %s", schema)
+	}
+	return fmt.Sprintf("TABLE %s.%s", schema, c.get("table_name"))
+}
+
+// Add prints SQL to add the schema or table comment
+func (c *CommentSchema) Add() {
+	if c.get("object_comment") == "null" || c.get("object_comment") == "" {
+		return
+	}
+
+	schema := dbInfo2.DbSchema
+	if schema == "*" {
+		schema = c.get("table_schema")
+	}
+
+	ref := ObjectRef{Schema: schema, Table: c.get("table_name"), Name: c.get("object_type")}
+	sqlText := fmt.Sprintf("COMMENT ON %s IS %s;\n", c.commentTarget(schema), quoteCommentLiteral(c.get("object_comment")))
+	emitSQL("add", ref, nil, toAnyMap(c.rows[c.rowNum]), sqlText)
+}
+
+// Drop does nothing -- a comment only exists on the schema or table it describes
+// and dropping the object (elsewhere) removes the comment along with it
+func (c *CommentSchema) Drop() {
+}
+
+// Change handles the case where the schema or table matches, but the comment does not
+func (c *CommentSchema) Change(obj interface{}) {
+	c2, ok := obj.(*CommentSchema)
+	if !ok {
+		fmt.Println("Error!!!, CommentSchema.Change(obj) needs a CommentSchema instance", c2)
+	}
+
+	if c.get("object_comment") == c2.get("object_comment") {
+		return
+	}
+
+	ref := ObjectRef{Schema: c2.get("table_schema"), Table: c.get("table_name"), Name: c.get("object_type")}
+	var sqlText string
+	if c.get("object_comment") == "null" || c.get("object_comment") == "" {
+		sqlText = fmt.Sprintf("COMMENT ON %s IS NULL;\n", c2.commentTarget(c2.get("table_schema")))
+	} else {
+		sqlText = fmt.Sprintf("COMMENT ON %s IS %s;\n", c2.commentTarget(c2.get("table_schema")), quoteCommentLiteral(c.get("object_comment")))
+	}
+	emitSQL("change", ref, toAnyMap(c2.rows[c2.rowNum]), toAnyMap(c.rows[c.rowNum]), sqlText)
+}
+
+// quoteCommentLiteral turns raw comment text into a single-quoted SQL string literal
+func quoteCommentLiteral(comment string) string {
+	return "'" + strings.Replace(comment, "'", "''", -1) + "'"
+}
+
+// ==================================
+// Standalone Functions
+// ==================================
+
+// compareComments outputs SQL to make schema and table comments match between two databases or schemas
+func compareComments(conn1 *sql.DB, conn2 *sql.DB) {
+	buf1 := new(bytes.Buffer)
+	commentSqlTemplate.Execute(buf1, dbInfo1)
+
+	buf2 := new(bytes.Buffer)
+	commentSqlTemplate.Execute(buf2, dbInfo2)
+
+	rowChan1, _ := pgutil.QueryStrings(conn1, buf1.String())
+	rowChan2, _ := pgutil.QueryStrings(conn2, buf2.String())
+
+	rows1 := make(CommentRows, 0)
+	for row := range rowChan1 {
+		rows1 = append(rows1, row)
+	}
+	sort.Sort(rows1)
+
+	rows2 := make(CommentRows, 0)
+	for row := range rowChan2 {
+		rows2 = append(rows2, row)
+	}
+	sort.Sort(rows2)
+
+	// We have to explicitly type this as Schema here for some unknown reason
+	var schema1 Schema = &CommentSchema{rows: rows1, rowNum: -1}
+	var schema2 Schema = &CommentSchema{rows: rows2, rowNum: -1}
+
+	// Compare the comments
+	doDiff(schema1, schema2)
+}
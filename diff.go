@@ -0,0 +1,98 @@
+//
+// Copyright (c) 2017 Jon Carlson.  All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// outputFormat controls how recorded changes are rendered: "sql" (default,
+// each statement is printed to stdout as it's produced, same as always) or
+// "json" (every change is buffered and rendered as one document via RenderJSON
+// once the diff finishes).
+var outputFormat = "sql"
+
+// ObjectRef identifies the database object a Change applies to
+type ObjectRef struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table,omitempty"`
+	Column string `json:"column,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Change is one typed add/drop/change record. SQL holds the rendered
+// statement(s) so a JSON diff can be replayed as SQL (see ApplyJSONDiff)
+// without re-connecting to the source databases.
+type Change struct {
+	Kind   string                 `json:"kind"` // "add", "drop", or "change"
+	Object ObjectRef              `json:"object"`
+	Before map[string]interface{} `json:"before,omitempty"`
+	After  map[string]interface{} `json:"after,omitempty"`
+	SQL    string                 `json:"sql"`
+}
+
+// changes accumulates every Change recorded this run via emitSQL so that
+// RenderJSON can render them as one document. It's only populated when
+// outputFormat is "json" -- the streaming SQL path (the default, and the
+// whole point of compare()'s streamDiff mode) must not hold the entire
+// diff in memory just to throw it away.
+var changes []Change
+
+// toAnyMap widens a row (map[string]string, as returned by pgutil.QueryStrings)
+// into the map[string]interface{} shape Change.Before/After expect
+func toAnyMap(row map[string]string) map[string]interface{} {
+	if row == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		out[k] = v
+	}
+	return out
+}
+
+// emitSQL records a Change when JSON output was requested; otherwise it
+// prints the SQL immediately and keeps nothing in memory -- the behavior
+// pgdiff has always had
+func emitSQL(kind string, ref ObjectRef, before, after map[string]interface{}, sqlText string) {
+	if outputFormat == "json" {
+		changes = append(changes, Change{Kind: kind, Object: ref, Before: before, After: after, SQL: sqlText})
+		return
+	}
+	fmt.Print(sqlText)
+}
+
+// RenderJSON prints every Change recorded so far as a single JSON array.
+// Call this once the diff has finished when outputFormat is "json".
+func RenderJSON() error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(changes)
+}
+
+// ApplyJSONDiff reads a JSON diff previously produced by RenderJSON -- after
+// a reviewer has pruned or reordered its entries -- and writes out the SQL
+// needed to apply it. This is the inverse of the compare-then-RenderJSON path.
+func ApplyJSONDiff(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening JSON diff %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var fromFile []Change
+	if err := json.NewDecoder(f).Decode(&fromFile); err != nil {
+		return fmt.Errorf("parsing JSON diff %s: %w", path, err)
+	}
+
+	for _, c := range fromFile {
+		fmt.Print(c.SQL)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+//
+// Copyright (c) 2017 Jon Carlson.  All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout runs fn with os.Stdout redirected to a pipe and returns
+// whatever it wrote.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	fn()
+
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading captured stdout: %v", err)
+	}
+	return string(out)
+}
+
+// TestRenderJSONThenApplyJSONDiff guards the round trip the request asked
+// for: a "json"-mode diff, written out by RenderJSON, can be fed back in by
+// ApplyJSONDiff and reproduces the same SQL.
+func TestRenderJSONThenApplyJSONDiff(t *testing.T) {
+	outputFormat = "json"
+	changes = nil
+	defer func() {
+		outputFormat = "sql"
+		changes = nil
+	}()
+
+	emitSQL("add", ObjectRef{Schema: "public", Table: "events", Column: "id"}, nil, nil, "ALTER TABLE public.events ADD COLUMN id integer;\n")
+	emitSQL("drop", ObjectRef{Schema: "public", Table: "events", Column: "old"}, nil, nil, "ALTER TABLE public.events DROP COLUMN IF EXISTS old;\n")
+
+	jsonOut := captureStdout(t, func() {
+		if err := RenderJSON(); err != nil {
+			t.Fatalf("RenderJSON() error: %v", err)
+		}
+	})
+
+	path := filepath.Join(t.TempDir(), "diff.json")
+	if err := os.WriteFile(path, []byte(jsonOut), 0o644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+
+	sqlOut := captureStdout(t, func() {
+		if err := ApplyJSONDiff(path); err != nil {
+			t.Fatalf("ApplyJSONDiff() error: %v", err)
+		}
+	})
+
+	for _, want := range []string{
+		"ALTER TABLE public.events ADD COLUMN id integer;",
+		"ALTER TABLE public.events DROP COLUMN IF EXISTS old;",
+	} {
+		if !strings.Contains(sqlOut, want) {
+			t.Errorf("ApplyJSONDiff output %q missing %q", sqlOut, want)
+		}
+	}
+}
+
+// TestApplyJSONDiffMissingFile confirms a missing path is reported as an
+// error rather than panicking.
+func TestApplyJSONDiffMissingFile(t *testing.T) {
+	err := ApplyJSONDiff(filepath.Join(t.TempDir(), "does-not-exist.json"))
+	if err == nil {
+		t.Fatal("ApplyJSONDiff() on a missing file: got nil error, want one")
+	}
+}
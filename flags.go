@@ -0,0 +1,74 @@
+//
+// Copyright (c) 2017 Jon Carlson.  All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joncrlsn/pgutil"
+)
+
+// schemaType selects which comparer(s) main runs: ALL, COLUMN, TABLE_COLUMN,
+// CHECK, or COMMENT.
+var schemaType string
+
+// castConfigPath, if set, is loaded into the cast registry via LoadCastConfig
+// once flags have been parsed.
+var castConfigPath string
+
+// applyJSONPath, if set, points main at a JSON diff (as produced by -format
+// json) to render as SQL instead of comparing two databases.
+var applyJSONPath string
+
+// parseFlags defines and parses the command line flags, populating
+// dbInfo1/dbInfo2 and the package-level switches that the comparers read
+// (outputFormat, safeMode, streamDiff).
+func parseFlags() {
+	dbInfo1 = &pgutil.DbInfo{}
+	dbInfo2 = &pgutil.DbInfo{}
+
+	flag.StringVar(&dbInfo1.DbHost, "h1", "localhost", "first database host")
+	flag.IntVar(&dbInfo1.DbPort, "p1", 5432, "first database port")
+	flag.StringVar(&dbInfo1.DbName, "d1", "", "first (desired-state) database name")
+	flag.StringVar(&dbInfo1.DbUser, "U1", "", "first database user")
+	flag.StringVar(&dbInfo1.DbPass, "P1", "", "first database password")
+	flag.StringVar(&dbInfo1.DbSchema, "s1", "public", `first database schema, or "*" for all non-system schemas`)
+
+	flag.StringVar(&dbInfo2.DbHost, "h2", "localhost", "second database host")
+	flag.IntVar(&dbInfo2.DbPort, "p2", 5432, "second database port")
+	flag.StringVar(&dbInfo2.DbName, "d2", "", "second (to-be-changed) database name")
+	flag.StringVar(&dbInfo2.DbUser, "U2", "", "second database user")
+	flag.StringVar(&dbInfo2.DbPass, "P2", "", "second database password")
+	flag.StringVar(&dbInfo2.DbSchema, "s2", "public", `second database schema, or "*" for all non-system schemas`)
+
+	flag.StringVar(&schemaType, "schemaType", "ALL", "what to compare: ALL, COLUMN, TABLE_COLUMN, CHECK, or COMMENT")
+	flag.StringVar(&outputFormat, "format", outputFormat, `output format: "sql" (default, streamed as produced) or "json" (buffered, rendered once at the end)`)
+	flag.BoolVar(&safeMode, "safe", false, "pre-flight validate lossy type changes and varchar shortenings before running them")
+	flag.StringVar(&castConfigPath, "cast-config", "", "path to a JSON file of additional CastRule entries to register")
+	flag.BoolVar(&streamDiff, "stream", false, "merge-join columns directly off the query result channels instead of buffering both sides")
+	flag.StringVar(&applyJSONPath, "apply-json", "", "path to a JSON diff (from -format json) to render as SQL; skips comparing -d1/-d2 entirely")
+
+	flag.Parse()
+
+	if applyJSONPath != "" {
+		// Rendering a previously-captured JSON diff doesn't touch either
+		// database, so -d1/-d2 aren't required in this mode.
+		return
+	}
+
+	if dbInfo1.DbName == "" || dbInfo2.DbName == "" {
+		fmt.Fprintln(os.Stderr, "both -d1 and -d2 are required")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if castConfigPath != "" {
+		check("loading cast config", LoadCastConfig(castConfigPath))
+	}
+}
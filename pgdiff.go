@@ -0,0 +1,121 @@
+//
+// Copyright (c) 2017 Jon Carlson.  All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joncrlsn/pgutil"
+)
+
+// Schema is implemented by every diffable object type (columns, table
+// columns, check constraints, comments, ...). doDiff drives any two
+// instances of the same type through a sorted merge-join: NextRow/Compare
+// walk both sides in lockstep and Add/Drop/Change fire the SQL needed to
+// make db2 match db1.
+type Schema interface {
+	NextRow() bool
+	Compare(interface{}) int
+	Add()
+	Drop()
+	Change(interface{})
+}
+
+// dbInfo1 and dbInfo2 hold the connection info for the two databases being
+// compared. db1 is the desired state; db2 is the one the generated SQL
+// targets to bring it in line with db1.
+var (
+	dbInfo1 *pgutil.DbInfo
+	dbInfo2 *pgutil.DbInfo
+)
+
+// check prints msg and exits if err is non-nil.  It exists so the many
+// one-off error checks scattered across the comparers don't each need
+// their own os.Exit boilerplate.
+func check(msg string, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", msg, err)
+		os.Exit(1)
+	}
+}
+
+// doDiff walks schema1 and schema2 in compare_name order.  A row found only
+// in schema1 is Add()-ed to db2; a row found only in schema2 is Drop()-ed
+// from db2; a row found in both is handed to schema1.Change(schema2).
+func doDiff(schema1 Schema, schema2 Schema) {
+	more1 := schema1.NextRow()
+	more2 := schema2.NextRow()
+
+	for more1 || more2 {
+		switch {
+		case more1 && !more2:
+			schema1.Add()
+			more1 = schema1.NextRow()
+		case !more1 && more2:
+			schema2.Drop()
+			more2 = schema2.NextRow()
+		default:
+			switch schema1.Compare(schema2) {
+			case -1:
+				schema1.Add()
+				more1 = schema1.NextRow()
+			case 1:
+				schema2.Drop()
+				more2 = schema2.NextRow()
+			default:
+				schema1.Change(schema2)
+				more1 = schema1.NextRow()
+				more2 = schema2.NextRow()
+			}
+		}
+	}
+}
+
+// main connects to both databases and runs whichever comparer(s) schemaType
+// selects, then (for outputFormat "json") renders the accumulated changes.
+func main() {
+	parseFlags()
+
+	if applyJSONPath != "" {
+		check("applying JSON diff", ApplyJSONDiff(applyJSONPath))
+		return
+	}
+
+	conn1, err := pgutil.OpenDb(*dbInfo1)
+	check("opening db1 connection", err)
+	defer conn1.Close()
+
+	conn2, err := pgutil.OpenDb(*dbInfo2)
+	check("opening db2 connection", err)
+	defer conn2.Close()
+
+	switch schemaType {
+	case "ALL":
+		// compareColumns is a strict superset of compareTableColumns (it also
+		// covers views), so ALL runs it alone -- running both would emit every
+		// base-table column's Add/Drop/Change twice.
+		compareColumns(conn1, conn2)
+		compareChecks(conn1, conn2)
+		compareComments(conn1, conn2)
+	case "COLUMN":
+		compareColumns(conn1, conn2)
+	case "TABLE_COLUMN":
+		compareTableColumns(conn1, conn2)
+	case "CHECK":
+		compareChecks(conn1, conn2)
+	case "COMMENT":
+		compareComments(conn1, conn2)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown -schemaType %q\n", schemaType)
+		os.Exit(1)
+	}
+
+	if outputFormat == "json" {
+		check("rendering JSON diff", RenderJSON())
+	}
+}
@@ -0,0 +1,90 @@
+//
+// Copyright (c) 2017 Jon Carlson.  All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package main
+
+import (
+	"strconv"
+)
+
+// streamDiff switches compare() from buffering both sides into a ColumnRows
+// slice and sort.Sort-ing them client-side over to a channel-based merge-join
+// that never holds more than one row per side in memory. The SQL templates
+// already say ORDER BY compare_name ASC, so the client-side sort was only
+// ever re-proving what the database already guaranteed.
+//
+// The buffered path remains the default for backward compatibility; doDiff
+// and the Schema interface themselves (pgdiff.go) still operate on fully
+// materialized Schema values for every other comparison (check constraints,
+// schema and table comments) and would need the same channel-based treatment
+// to get the same memory win there.
+var streamDiff bool
+
+// ColumnMeta holds a column row's numeric attributes as actual Go integers
+// instead of strings, so character_maximum_length doesn't silently truncate
+// on a 32-bit host the way a naive string round-trip can.
+type ColumnMeta struct {
+	CharacterMaximumLength    int64
+	HasCharacterMaximumLength bool
+}
+
+// parseColumnMeta extracts the numeric fields of a column row into a ColumnMeta
+func parseColumnMeta(row map[string]string) ColumnMeta {
+	var meta ColumnMeta
+	if raw := row["character_maximum_length"]; raw != "" && raw != "null" {
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			meta.CharacterMaximumLength = n
+			meta.HasCharacterMaximumLength = true
+		}
+	}
+	return meta
+}
+
+// rowStream wraps a row channel with one row of lookahead so a merge-join can
+// peek both sides without buffering either one
+type rowStream struct {
+	ch   <-chan map[string]string
+	next map[string]string
+	ok   bool
+}
+
+func newRowStream(ch <-chan map[string]string) *rowStream {
+	s := &rowStream{ch: ch}
+	s.advance()
+	return s
+}
+
+func (s *rowStream) advance() {
+	s.next, s.ok = <-s.ch
+}
+
+// mergeDiffColumns performs a classic sorted merge-join over the two row
+// channels, comparing on compare_name and calling Add/Drop/Change exactly as
+// doDiff does for the buffered ColumnSchema path, but without ever holding
+// more than one row per side in memory.
+func mergeDiffColumns(rowChan1, rowChan2 <-chan map[string]string) {
+	s1 := newRowStream(rowChan1)
+	s2 := newRowStream(rowChan2)
+
+	for s1.ok || s2.ok {
+		switch {
+		case s1.ok && (!s2.ok || s1.next["compare_name"] < s2.next["compare_name"]):
+			schema := &ColumnSchema{rows: ColumnRows{s1.next}, rowNum: 0}
+			schema.Add()
+			s1.advance()
+		case s2.ok && (!s1.ok || s2.next["compare_name"] < s1.next["compare_name"]):
+			schema := &ColumnSchema{rows: ColumnRows{s2.next}, rowNum: 0}
+			schema.Drop()
+			s2.advance()
+		default:
+			schema1 := &ColumnSchema{rows: ColumnRows{s1.next}, rowNum: 0}
+			schema2 := &ColumnSchema{rows: ColumnRows{s2.next}, rowNum: 0}
+			schema1.Change(schema2)
+			s1.advance()
+			s2.advance()
+		}
+	}
+}
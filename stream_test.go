@@ -0,0 +1,135 @@
+//
+// Copyright (c) 2017 Jon Carlson.  All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package main
+
+import (
+	"testing"
+
+	"github.com/joncrlsn/pgutil"
+)
+
+func TestParseColumnMeta(t *testing.T) {
+	cases := []struct {
+		name string
+		row  map[string]string
+		want ColumnMeta
+	}{
+		{
+			name: "max length present",
+			row:  map[string]string{"character_maximum_length": "255"},
+			want: ColumnMeta{CharacterMaximumLength: 255, HasCharacterMaximumLength: true},
+		},
+		{
+			name: "null max length",
+			row:  map[string]string{"character_maximum_length": "null"},
+			want: ColumnMeta{},
+		},
+		{
+			name: "empty max length",
+			row:  map[string]string{"character_maximum_length": ""},
+			want: ColumnMeta{},
+		},
+		{
+			name: "garbage max length",
+			row:  map[string]string{"character_maximum_length": "not-a-number"},
+			want: ColumnMeta{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := parseColumnMeta(c.row)
+			if got != c.want {
+				t.Errorf("parseColumnMeta(%v) = %+v, want %+v", c.row, got, c.want)
+			}
+		})
+	}
+}
+
+// column builds a minimal column row with the fields ColumnSchema.Add/Drop/
+// Change touch, defaulting the rest to values that make them no-ops.
+func column(tableSchema, tableName, columnName string) map[string]string {
+	return map[string]string{
+		"table_schema":             tableSchema,
+		"table_name":               tableName,
+		"column_name":              columnName,
+		"compare_name":             tableName + "." + columnName,
+		"data_type":                "integer",
+		"is_nullable":              "YES",
+		"column_default":           "null",
+		"character_maximum_length": "null",
+		"is_identity":              "NO",
+		"identity_generation":      "null",
+		"generation_expression":    "null",
+		"array_type":               "",
+		"collation_name":           "null",
+		"storage":                  "",
+		"compression":              "",
+		"column_comment":           "null",
+	}
+}
+
+func TestMergeDiffColumns(t *testing.T) {
+	outputFormat = "json"
+	changes = nil
+	dbInfo2 = &pgutil.DbInfo{DbSchema: "public"}
+	defer func() {
+		outputFormat = "sql"
+		changes = nil
+		dbInfo2 = nil
+	}()
+
+	// only_in_1.a should be added to db2; only_in_2.b should be dropped from
+	// db2; same.c is identical on both sides and should produce nothing.
+	rows1 := []map[string]string{
+		column("public", "only_in_1", "a"),
+		column("public", "same", "c"),
+	}
+	rows2 := []map[string]string{
+		column("public", "only_in_2", "b"),
+		column("public", "same", "c"),
+	}
+
+	rowChan1 := make(chan map[string]string, len(rows1))
+	for _, r := range rows1 {
+		rowChan1 <- r
+	}
+	close(rowChan1)
+
+	rowChan2 := make(chan map[string]string, len(rows2))
+	for _, r := range rows2 {
+		rowChan2 <- r
+	}
+	close(rowChan2)
+
+	mergeDiffColumns(rowChan1, rowChan2)
+
+	var adds, drops int
+	for _, c := range changes {
+		switch c.Kind {
+		case "add":
+			adds++
+			if c.Object.Table != "only_in_1" {
+				t.Errorf("unexpected add for table %q", c.Object.Table)
+			}
+		case "drop":
+			drops++
+			if c.Object.Table != "only_in_2" {
+				t.Errorf("unexpected drop for table %q", c.Object.Table)
+			}
+		default:
+			t.Errorf("unexpected change kind %q for table %q", c.Kind, c.Object.Table)
+		}
+	}
+
+	if adds != 1 {
+		t.Errorf("got %d adds, want 1", adds)
+	}
+	if drops != 1 {
+		t.Errorf("got %d drops, want 1", drops)
+	}
+}